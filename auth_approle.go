@@ -0,0 +1,98 @@
+/*
+Copyright 2015 Home Office All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/hashicorp/vault/api"
+)
+
+// appRolePlugin ... implements the AuthInterface against vault's approle auth backend
+type appRolePlugin struct {
+	// client is the vault client used to authenticate
+	client *api.Client
+}
+
+// NewAppRolePlugin ... creates a new approle authentication plugin
+func NewAppRolePlugin(client *api.Client) AuthInterface {
+	return &appRolePlugin{client: client}
+}
+
+// Create ... logs into the approle backend and returns the client token
+//	auth		: a map containing role_id and either secret_id, secret_id_file or wrap_token
+func (r *appRolePlugin) Create(auth map[string]string) (string, error) {
+	roleID, found := auth["role_id"]
+	if !found {
+		return "", fmt.Errorf("you have not specified the role_id for the approle authentication")
+	}
+
+	secretID, err := r.secretID(auth)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := r.client.Logical().Write("auth/approle/login", map[string]interface{}{
+		"role_id":   roleID,
+		"secret_id": secretID,
+	})
+	if err != nil {
+		return "", err
+	}
+	if secret == nil || secret.Auth == nil {
+		return "", fmt.Errorf("no authentication information returned by vault")
+	}
+
+	return secret.Auth.ClientToken, nil
+}
+
+// secretID ... works out the secret_id to login with: a wrapped token takes priority (the
+// approle "secure introduction" pattern), then a file on disk, then the raw value
+func (r *appRolePlugin) secretID(auth map[string]string) (string, error) {
+	if wrapToken, found := auth["wrap_token"]; found && wrapToken != "" {
+		glog.V(3).Infof("unwrapping the secret_id from the supplied wrap_token")
+		secret, err := r.client.Logical().Unwrap(wrapToken)
+		if err != nil {
+			return "", fmt.Errorf("unable to unwrap the secret_id, error: %s", err)
+		}
+		secretID, ok := secret.Data["secret_id"].(string)
+		if !ok {
+			return "", fmt.Errorf("the unwrapped response did not contain a secret_id")
+		}
+
+		return secretID, nil
+	}
+
+	if filename, found := auth["secret_id_file"]; found && filename != "" {
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", fmt.Errorf("unable to read the secret_id file: %s, error: %s", filename, err)
+		}
+
+		return strings.TrimSpace(string(content)), nil
+	}
+
+	secretID, found := auth["secret_id"]
+	if !found {
+		return "", fmt.Errorf("you have not specified the secret_id for the approle authentication")
+	}
+
+	return secretID, nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2015 Home Office All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// kubernetesServiceAccountTokenFile ... the default location of the projected service account
+// jwt, overridable via the jwt_file auth option
+const kubernetesServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// kubernetesPlugin ... implements the AuthInterface against vault's kubernetes auth backend
+type kubernetesPlugin struct {
+	// client is the vault client used to authenticate
+	client *api.Client
+}
+
+// NewKubernetesPlugin ... creates a new kubernetes authentication plugin
+func NewKubernetesPlugin(client *api.Client) AuthInterface {
+	return &kubernetesPlugin{client: client}
+}
+
+// Create ... logs into the kubernetes backend using the pod's service account jwt
+//	auth		: a map containing role, and optionally jwt_file and mount overrides
+func (r *kubernetesPlugin) Create(auth map[string]string) (string, error) {
+	role, found := auth["role"]
+	if !found {
+		return "", fmt.Errorf("you have not specified the role for the kubernetes authentication")
+	}
+
+	tokenFile := kubernetesServiceAccountTokenFile
+	if path, found := auth["jwt_file"]; found && path != "" {
+		tokenFile = path
+	}
+
+	jwt, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the service account token: %s, error: %s", tokenFile, err)
+	}
+
+	loginPath := "auth/kubernetes/login"
+	if mount, found := auth["mount"]; found && mount != "" {
+		loginPath = fmt.Sprintf("auth/%s/login", mount)
+	}
+
+	secret, err := r.client.Logical().Write(loginPath, map[string]interface{}{
+		"role": role,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	})
+	if err != nil {
+		return "", err
+	}
+	if secret == nil || secret.Auth == nil {
+		return "", fmt.Errorf("no authentication information returned by vault")
+	}
+
+	return secret.Auth.ClientToken, nil
+}
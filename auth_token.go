@@ -0,0 +1,58 @@
+/*
+Copyright 2015 Home Office All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// userTokenPlugin ... implements the AuthInterface by simply handing back a pre-existing client
+// token, either supplied directly or read from a file
+type userTokenPlugin struct {
+	// client is unused but kept so every auth plugin shares the same constructor shape
+	client *api.Client
+}
+
+// NewUserTokenPlugin ... creates a new token authentication plugin
+func NewUserTokenPlugin(client *api.Client) AuthInterface {
+	return &userTokenPlugin{client: client}
+}
+
+// Create ... returns the client token, read from the filename set by the caller or, failing
+// that, the token option directly
+//	auth		: a map containing filename and/or token
+func (r *userTokenPlugin) Create(auth map[string]string) (string, error) {
+	if filename, found := auth["filename"]; found && filename != "" {
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", fmt.Errorf("unable to read the token file: %s, error: %s", filename, err)
+		}
+
+		return strings.TrimSpace(string(content)), nil
+	}
+
+	token, found := auth["token"]
+	if !found {
+		return "", fmt.Errorf("you have not specified the token for the token authentication")
+	}
+
+	return token, nil
+}
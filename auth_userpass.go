@@ -0,0 +1,59 @@
+/*
+Copyright 2015 Home Office All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// userPassPlugin ... implements the AuthInterface against vault's userpass auth backend
+type userPassPlugin struct {
+	// client is the vault client used to authenticate
+	client *api.Client
+}
+
+// NewUserPassPlugin ... creates a new userpass authentication plugin
+func NewUserPassPlugin(client *api.Client) AuthInterface {
+	return &userPassPlugin{client: client}
+}
+
+// Create ... logs into the userpass backend and returns the client token
+//	auth		: a map containing username and password
+func (r *userPassPlugin) Create(auth map[string]string) (string, error) {
+	username, found := auth["username"]
+	if !found {
+		return "", fmt.Errorf("you have not specified the username for the userpass authentication")
+	}
+	password, found := auth["password"]
+	if !found {
+		return "", fmt.Errorf("you have not specified the password for the userpass authentication")
+	}
+
+	secret, err := r.client.Logical().Write(fmt.Sprintf("auth/userpass/login/%s", username), map[string]interface{}{
+		"password": password,
+	})
+	if err != nil {
+		return "", err
+	}
+	if secret == nil || secret.Auth == nil {
+		return "", fmt.Errorf("no authentication information returned by vault")
+	}
+
+	return secret.Auth.ClientToken, nil
+}
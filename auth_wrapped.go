@@ -0,0 +1,99 @@
+/*
+Copyright 2015 Home Office All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/hashicorp/vault/api"
+)
+
+// wrappedPlugin ... implements the AuthInterface against a response-wrapped auth secret, the
+// approle "secure introduction" pattern: some other system hands the operator a wrap token
+// rather than a raw role_id/secret_id or client token
+type wrappedPlugin struct {
+	// client is the vault client used to unwrap and authenticate
+	client *api.Client
+}
+
+// NewWrappedPlugin ... creates a new response-wrapping authentication plugin
+func NewWrappedPlugin(client *api.Client) AuthInterface {
+	return &wrappedPlugin{client: client}
+}
+
+// Create ... unwraps the supplied token and returns the client token it contained
+//	auth		: a map containing either token or token_file
+func (r *wrappedPlugin) Create(auth map[string]string) (string, error) {
+	wrapToken, err := r.wrapToken(auth)
+	if err != nil {
+		return "", err
+	}
+
+	// step: refuse to proceed if the token has already been consumed (or never existed) -
+	// looking it up first means we never attempt to authenticate with a dead token
+	if err := r.audit(wrapToken); err != nil {
+		return "", err
+	}
+
+	secret, err := r.client.Logical().Unwrap(wrapToken)
+	if err != nil {
+		return "", fmt.Errorf("unable to unwrap the auth response, error: %s", err)
+	}
+	if secret == nil || secret.Auth == nil {
+		return "", fmt.Errorf("the unwrapped response did not contain an authentication secret")
+	}
+
+	return secret.Auth.ClientToken, nil
+}
+
+// audit ... looks up the wrap token before it's consumed so an already-used or invalid token
+// is rejected up front, logging the creation path for operators to audit
+func (r *wrappedPlugin) audit(wrapToken string) error {
+	lookup, err := r.client.Logical().Write("sys/wrapping/lookup", map[string]interface{}{"token": wrapToken})
+	if err != nil {
+		return fmt.Errorf("the supplied wrap token has already been used or is invalid, error: %s", err)
+	}
+	if lookup == nil {
+		return fmt.Errorf("the supplied wrap token has already been used or is invalid")
+	}
+
+	glog.Infof("consuming wrap token created at path: %v, creation ttl: %v", lookup.Data["creation_path"], lookup.Data["creation_ttl"])
+
+	return nil
+}
+
+// wrapToken ... works out the wrap token to unwrap, either directly or from a file
+func (r *wrappedPlugin) wrapToken(auth map[string]string) (string, error) {
+	if filename, found := auth["token_file"]; found && filename != "" {
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", fmt.Errorf("unable to read the wrap token file: %s, error: %s", filename, err)
+		}
+
+		return strings.TrimSpace(string(content)), nil
+	}
+
+	token, found := auth["token"]
+	if !found {
+		return "", fmt.Errorf("you have not specified the token for the wrapped authentication")
+	}
+
+	return token, nil
+}
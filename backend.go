@@ -0,0 +1,212 @@
+/*
+Copyright 2015 Home Office All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sync"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// backend ... is implemented by each supported vault secret engine, so adding a new one means
+// adding an implementation rather than growing VaultService.get's switch statement
+type backend interface {
+	// Fetch retrieves (or, for write-only engines such as pki, generates) the secret for a resource
+	Fetch(client *api.Client, resource *VaultResource) (*api.Secret, error)
+}
+
+// backends ... maps a resource's engine name to the backend responsible for fetching it
+var backends = map[string]backend{
+	"pki":      pkiBackend{},
+	"aws":      genericCredsBackend{},
+	"mysql":    genericCredsBackend{},
+	"database": genericCredsBackend{},
+	"secret":   legacySecretBackend{},
+	"kv":       &kvBackend{},
+	"transit":  transitBackend{},
+	"ssh":      sshBackend{},
+}
+
+// pkiBackend ... issues a new certificate from a pki role
+type pkiBackend struct{}
+
+// Fetch ... writes to <mount>/issue/<name> with the configured common_name
+func (pkiBackend) Fetch(client *api.Client, resource *VaultResource) (*api.Secret, error) {
+	return client.Logical().Write(fmt.Sprintf("%s/issue/%s", resource.resource, resource.name),
+		map[string]interface{}{
+			"common_name": resource.options[OptionCommonName],
+		})
+}
+
+// genericCredsBackend ... reads leased, renewable dynamic credentials from a <mount>/creds/<role>
+// endpoint - used by aws, mysql and the other database-style secret engines that share this shape
+type genericCredsBackend struct{}
+
+// Fetch ... reads <mount>/creds/<name>
+func (genericCredsBackend) Fetch(client *api.Client, resource *VaultResource) (*api.Secret, error) {
+	return client.Logical().Read(fmt.Sprintf("%s/creds/%s", resource.resource, resource.name))
+}
+
+// legacySecretBackend ... reads a plain kv v1 style secret at <mount>/<name>, kept for the
+// original "secret/" mount convention
+type legacySecretBackend struct{}
+
+// Fetch ... reads <mount>/<name>
+func (legacySecretBackend) Fetch(client *api.Client, resource *VaultResource) (*api.Secret, error) {
+	return client.Logical().Read(fmt.Sprintf("%s/%s", resource.resource, resource.name))
+}
+
+// kvBackend ... reads from the kv secrets engine, transparently supporting both v1 and v2 - the
+// mount version is looked up once via sys/internal/ui/mounts and cached thereafter
+type kvBackend struct {
+	// mountVersions caches the kv version (1 or 2) already discovered for a given mount
+	mountVersions sync.Map
+}
+
+// Fetch ... reads <mount>/data/<name> for a v2 mount, or <mount>/<name> for v1, unwrapping the
+// v2 data.data envelope and honouring an options["version"] pin; kv secrets carry no lease so
+// the resource's own refresh interval is used as the lease duration
+func (b *kvBackend) Fetch(client *api.Client, resource *VaultResource) (*api.Secret, error) {
+	mount := resource.resource
+
+	version, err := b.mountVersion(client, mount)
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf("%s/%s", mount, resource.name)
+	if version == 2 {
+		path = fmt.Sprintf("%s/data/%s", mount, resource.name)
+	}
+	if v, found := resource.options[OptionVersion]; found && v != "" {
+		path = fmt.Sprintf("%s?%s", path, url.Values{"version": []string{v}}.Encode())
+	}
+
+	secret, err := client.Logical().Read(path)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("no secret found at: %s", path)
+	}
+
+	if version == 2 {
+		data, ok := secret.Data["data"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected kv v2 response shape at: %s", path)
+		}
+		secret.Data = data
+	}
+
+	secret.Renewable = false
+	secret.LeaseDuration = int(resource.update.Seconds())
+
+	return secret, nil
+}
+
+// mountVersion ... looks up, and caches, whether a kv mount is v1 or v2
+func (b *kvBackend) mountVersion(client *api.Client, mount string) (int, error) {
+	if cached, found := b.mountVersions.Load(mount); found {
+		return cached.(int), nil
+	}
+
+	info, err := client.Logical().Read(fmt.Sprintf("sys/internal/ui/mounts/%s", mount))
+	if err != nil || info == nil {
+		// step: older vault servers don't expose this endpoint - fall back to v1
+		b.mountVersions.Store(mount, 1)
+		return 1, nil
+	}
+
+	version := 1
+	if raw, ok := info.Data["options"].(map[string]interface{}); ok {
+		if v, ok := raw["version"].(string); ok && v == "2" {
+			version = 2
+		}
+	}
+	b.mountVersions.Store(mount, version)
+
+	return version, nil
+}
+
+// transitBackend ... drives the transit engine: encrypt / decrypt a supplied plaintext or
+// ciphertext, or periodically retrieve a datakey for envelope encryption
+type transitBackend struct{}
+
+// Fetch ... dispatches on options[OptionTransitOperation], defaulting to datakey retrieval;
+// like kv, none of these responses carry a lease, so the resource's own refresh interval is
+// used as the lease duration
+func (transitBackend) Fetch(client *api.Client, resource *VaultResource) (*api.Secret, error) {
+	var secret *api.Secret
+	var err error
+
+	switch resource.options[OptionTransitOperation] {
+	case "encrypt":
+		secret, err = client.Logical().Write(fmt.Sprintf("%s/encrypt/%s", resource.resource, resource.name), map[string]interface{}{
+			"plaintext": resource.options[OptionPlaintext],
+		})
+	case "decrypt":
+		secret, err = client.Logical().Write(fmt.Sprintf("%s/decrypt/%s", resource.resource, resource.name), map[string]interface{}{
+			"ciphertext": resource.options[OptionCiphertext],
+		})
+	default:
+		secret, err = client.Logical().Read(fmt.Sprintf("%s/datakey/plaintext/%s", resource.resource, resource.name))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("no secret returned for resource: %s", resource)
+	}
+
+	secret.Renewable = false
+	secret.LeaseDuration = int(resource.update.Seconds())
+
+	return secret, nil
+}
+
+// sshBackend ... signs a public key against an ssh ca role
+type sshBackend struct{}
+
+// Fetch ... writes ssh/sign/<role> with the public_key read from the options[OptionPublicKeyFile];
+// a signed certificate carries no lease, so the resource's own refresh interval is used as the
+// lease duration, the same way kv does
+func (sshBackend) Fetch(client *api.Client, resource *VaultResource) (*api.Secret, error) {
+	publicKeyFile := resource.options[OptionPublicKeyFile]
+
+	publicKey, err := ioutil.ReadFile(publicKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the public key file: %s, error: %s", publicKeyFile, err)
+	}
+
+	secret, err := client.Logical().Write(fmt.Sprintf("%s/sign/%s", resource.resource, resource.name), map[string]interface{}{
+		"public_key": string(publicKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("no secret returned for resource: %s", resource)
+	}
+
+	secret.Renewable = false
+	secret.LeaseDuration = int(resource.update.Seconds())
+
+	return secret, nil
+}
@@ -46,7 +46,7 @@ func main() {
 	}
 
 	// step: setup the termination signals
-	signalChannel := make(chan os.Signal)
+	signalChannel := make(chan os.Signal, 1)
 	signal.Notify(signalChannel, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
 	// step: create a channel to receive events upon and add our resources for renewal
@@ -65,10 +65,16 @@ func main() {
 	for {
 		select {
 		case evt := <-updates:
-			go writeResource(evt.Resource, evt.Secret)
+			switch evt.Kind {
+			case ResourceUpdated:
+				go writeResource(evt.Resource, evt.Secret)
+			case ReAuthenticated:
+				glog.Infof("the client token was renewed by re-authenticating against vault")
+			}
 
 		case <-signalChannel:
 			glog.Infof("recieved a termination signal, shutting down the service")
+			vault.Close()
 			os.Exit(0)
 		}
 	}
@@ -0,0 +1,154 @@
+/*
+Copyright 2015 Home Office All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Options ... the command line / environment configuration for the service
+type Options struct {
+	// vaultURL is the address of the vault service to speak to
+	vaultURL string
+	// vaultCaFile is an optional path to a PEM encoded CA bundle used to verify the vault server
+	vaultCaFile string
+	// vaultAuthFile is the file a "token" auth method reads the client token from
+	vaultAuthFile string
+	// vaultAuthOptions is the parsed "-auth" flag, e.g. method=approle,role_id=..,secret_id=..
+	vaultAuthOptions map[string]string
+	// tlsVerify, when false, disables TLS certificate verification against the vault server
+	tlsVerify bool
+	// statsInterval is how often the service processor logs what it is watching
+	statsInterval time.Duration
+	// resources is the set of resources parsed off the repeated "-resource" flag
+	resources vaultResources
+}
+
+// options ... the global, parsed command line / environment configuration
+var options Options
+
+// vaultResources ... a repeatable flag.Value collecting every "-resource" specification
+type vaultResources struct {
+	items []*VaultResource
+}
+
+// String ... satisfies flag.Value
+func (r *vaultResources) String() string {
+	specs := make([]string, len(r.items))
+	for i, rn := range r.items {
+		specs[i] = rn.String()
+	}
+
+	return strings.Join(specs, ",")
+}
+
+// Set ... satisfies flag.Value, parsing and appending one "-resource" specification
+func (r *vaultResources) Set(spec string) error {
+	rn, err := parseResource(spec)
+	if err != nil {
+		return err
+	}
+	r.items = append(r.items, rn)
+
+	return nil
+}
+
+// authMethods ... the auth methods recognised by the "method" key of the "-auth" flag, kept
+// here purely so -help and a bad method name are caught before we ever try to talk to vault
+var authMethods = []string{"userpass", "token", "approle", "kubernetes", "wrapped"}
+
+// parseAuthOptions ... parses the "-auth" flag, a comma separated list of key=value pairs, e.g.
+// "method=approle,role_id=xxx,secret_id=yyy" - passed straight through to the auth plugin
+// selected by the "method" key, so adding a new plugin only means adding it to authMethods
+func parseAuthOptions(spec string) (map[string]string, error) {
+	auth := make(map[string]string)
+	if spec == "" {
+		return auth, nil
+	}
+
+	for _, field := range strings.Split(spec, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid auth option: %s, expected key=value", field)
+		}
+		auth[kv[0]] = kv[1]
+	}
+
+	method, found := auth[VaultAuth]
+	if found {
+		valid := false
+		for _, known := range authMethods {
+			if method == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unsupported authentication method: %s, must be one of: %s",
+				method, strings.Join(authMethods, ", "))
+		}
+	}
+
+	return auth, nil
+}
+
+// parseOptions ... parses and validates the command line / environment options into the
+// global options variable
+func parseOptions() error {
+	var authSpec string
+
+	flag.StringVar(&options.vaultURL, "vault", "https://127.0.0.1:8200", "the url the vault service is running under")
+	flag.StringVar(&options.vaultCaFile, "ca-cert", "", "the path to a PEM encoded CA bundle used to verify the vault server")
+	flag.StringVar(&options.vaultAuthFile, "auth-file", "", "the file to read a raw client token from when using the token auth method")
+	flag.StringVar(&authSpec, "auth", "", fmt.Sprintf(
+		"the authentication method and options, e.g. method=userpass,username=x,password=y "+
+			"(method must be one of: %s)", strings.Join(authMethods, ", ")))
+	flag.BoolVar(&options.tlsVerify, "tls-skip-verify", false, "whether to skip tls certificate verification against the vault server")
+	flag.DurationVar(&options.statsInterval, "stats-interval", 5*time.Minute, "how often to log what resources are being watched")
+	flag.Var(&options.resources, "resource", "a resource to retrieve and keep fresh, e.g. "+
+		"pki/example.com:/etc/ssl/example.com,common_name=example.com (may be repeated). "+
+		"Supports the pki, aws, mysql, database, secret, kv, transit and ssh engines, with "+
+		"engine-specific options such as common_name, version, operation, plaintext, "+
+		"ciphertext, public_key_file and wrap_ttl")
+	flag.Parse()
+
+	auth, err := parseAuthOptions(authSpec)
+	if err != nil {
+		return err
+	}
+	options.vaultAuthOptions = auth
+
+	if _, found := auth[VaultAuth]; !found {
+		return fmt.Errorf("the -auth option must specify a %q, e.g. method=userpass", VaultAuth)
+	}
+	if len(options.resources.items) == 0 {
+		return fmt.Errorf("you have not specified any resources to watch via -resource")
+	}
+
+	return nil
+}
+
+// showUsage ... prints the supplied message along with the command line usage and exits
+func showUsage(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	flag.Usage()
+	os.Exit(1)
+}
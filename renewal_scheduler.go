@@ -0,0 +1,199 @@
+/*
+Copyright 2015 Home Office All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"container/heap"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// schedulerBackoffBase is the starting point for the capped exponential backoff applied
+	// to a task which keeps failing
+	schedulerBackoffBase = 2 * time.Second
+	// schedulerBackoffCap is the ceiling a failing task's backoff will never exceed
+	schedulerBackoffCap = 5 * time.Minute
+)
+
+// schedulerTask ... a single unit of retry-able work pending in the renewalScheduler
+type schedulerTask struct {
+	// runAt is when this task becomes due
+	runAt time.Time
+	// label identifies the task for logging purposes, e.g. "fetch:<resource>"
+	label string
+	// fn is invoked once the task comes due; returning a non-nil error reschedules it with
+	// the backoff advanced by one more attempt
+	fn func() error
+	// attempt is the number of consecutive failures seen for this task, growing the backoff
+	// and reset implicitly by simply not being rescheduled on success
+	attempt int
+	// index is maintained by container/heap, required to satisfy heap.Interface
+	index int
+}
+
+// taskHeap ... a container/heap min-heap of schedulerTasks ordered by runAt
+type taskHeap []*schedulerTask
+
+func (h taskHeap) Len() int            { return len(h) }
+func (h taskHeap) Less(i, j int) bool  { return h[i].runAt.Before(h[j].runAt) }
+func (h taskHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *taskHeap) Push(x interface{}) {
+	task := x.(*schedulerTask)
+	task.index = len(*h)
+	*h = append(*h, task)
+}
+
+func (h *taskHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	task := old[n-1]
+	old[n-1] = nil
+	task.index = -1
+	*h = old[:n-1]
+
+	return task
+}
+
+// renewalScheduler ... a min-heap of pending retry attempts backed by a single time.Timer armed
+// to the heap root. Replaces the old model of one reschedule() goroutine + randomWait per
+// resource, which scales badly once you're watching hundreds of secrets and treats a transient
+// vault outage the same as a steady-state sleep.
+type renewalScheduler struct {
+	lock  sync.Mutex
+	tasks taskHeap
+	timer *time.Timer
+}
+
+// newRenewalScheduler ... creates an empty scheduler, its timer stopped until something is scheduled
+func newRenewalScheduler() *renewalScheduler {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+
+	return &renewalScheduler{timer: timer}
+}
+
+// Start ... runs the scheduler's dispatch loop until the shutdown channel is closed
+func (s *renewalScheduler) Start(shutdown <-chan struct{}) {
+	go func() {
+		for {
+			select {
+			case <-s.timer.C:
+				s.runDue()
+				s.arm()
+
+			case <-shutdown:
+				s.timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// schedule ... enqueues a task to run as soon as possible, e.g. a fresh retrieval, renewal
+// or revoke attempt coming off the resource pipeline
+//	label	: identifies the task for logging purposes
+//	fn		: the work to perform once the task is due
+func (s *renewalScheduler) schedule(label string, fn func() error) {
+	s.lock.Lock()
+	heap.Push(&s.tasks, &schedulerTask{runAt: time.Now(), label: label, fn: fn})
+	s.lock.Unlock()
+
+	s.arm()
+}
+
+// Len ... the number of tasks currently pending, exposed purely for stats
+func (s *renewalScheduler) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return len(s.tasks)
+}
+
+// runDue ... pops and runs every task whose runAt has arrived, each in its own goroutine so a
+// slow or failing task can never block the dispatch loop or its neighbours
+func (s *renewalScheduler) runDue() {
+	for {
+		s.lock.Lock()
+		if len(s.tasks) == 0 || s.tasks[0].runAt.After(time.Now()) {
+			s.lock.Unlock()
+			return
+		}
+		task := heap.Pop(&s.tasks).(*schedulerTask)
+		s.lock.Unlock()
+
+		go s.run(task)
+	}
+}
+
+// run ... executes a due task, rescheduling it with backoff on failure
+func (s *renewalScheduler) run(task *schedulerTask) {
+	if err := task.fn(); err != nil {
+		glog.Errorf("scheduled task: %s failed, error: %s, backing off", task.label, err)
+		s.retry(task)
+	}
+}
+
+// retry ... reschedules a failed task with capped exponential backoff and full jitter
+func (s *renewalScheduler) retry(task *schedulerTask) {
+	task.attempt++
+
+	backoff := schedulerBackoffBase * time.Duration(int64(1)<<uint(task.attempt-1))
+	if backoff <= 0 || backoff > schedulerBackoffCap {
+		backoff = schedulerBackoffCap
+	}
+	// step: full jitter - sleep somewhere between zero and the capped backoff so a pile of
+	// simultaneously failing resources don't all retry in lockstep
+	task.runAt = time.Now().Add(time.Duration(rand.Int63n(int64(backoff))))
+
+	s.lock.Lock()
+	heap.Push(&s.tasks, task)
+	s.lock.Unlock()
+
+	s.arm()
+}
+
+// arm ... (re)sets the timer to fire when the heap root is next due. Must be called any time
+// the root of the heap could have changed - after a push or a retry.
+func (s *renewalScheduler) arm() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.timer.Stop() {
+		select {
+		case <-s.timer.C:
+		default:
+		}
+	}
+
+	if len(s.tasks) == 0 {
+		return
+	}
+
+	wait := time.Until(s.tasks[0].runAt)
+	if wait < 0 {
+		wait = 0
+	}
+	s.timer.Reset(wait)
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2015 Home Office All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"container/heap"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskHeapOrdersByRunAt(t *testing.T) {
+	now := time.Now()
+	var tasks taskHeap
+	heap.Init(&tasks)
+
+	heap.Push(&tasks, &schedulerTask{label: "third", runAt: now.Add(3 * time.Second)})
+	heap.Push(&tasks, &schedulerTask{label: "first", runAt: now.Add(1 * time.Second)})
+	heap.Push(&tasks, &schedulerTask{label: "second", runAt: now.Add(2 * time.Second)})
+
+	require.Equal(t, 3, tasks.Len())
+
+	var popped []string
+	for tasks.Len() > 0 {
+		popped = append(popped, heap.Pop(&tasks).(*schedulerTask).label)
+	}
+
+	assert.Equal(t, []string{"first", "second", "third"}, popped)
+}
+
+func TestRenewalSchedulerLen(t *testing.T) {
+	scheduler := newRenewalScheduler()
+
+	assert.Equal(t, 0, scheduler.Len())
+
+	failing := make(chan struct{})
+	scheduler.schedule("blocked", func() error {
+		<-failing
+		return nil
+	})
+
+	// step: give the (unstarted) scheduler's task a moment to be popped were it running -
+	// it isn't, since Start was never called, so Len should still reflect the pending task
+	assert.Equal(t, 1, scheduler.Len())
+	close(failing)
+}
+
+func TestRenewalSchedulerRetryBackoffIsCappedAndJittered(t *testing.T) {
+	scheduler := newRenewalScheduler()
+	task := &schedulerTask{label: "retry-me", attempt: 0}
+
+	before := time.Now()
+	scheduler.retry(task)
+
+	assert.Equal(t, 1, task.attempt)
+	wait := task.runAt.Sub(before)
+	assert.True(t, wait >= 0, "backoff must never be negative")
+	assert.True(t, wait <= schedulerBackoffBase, "a first attempt must back off within [0, base]")
+
+	// step: attempt high enough that 2^(attempt-1) overflows the cap many times over - backoff
+	// must still be clamped rather than wrapping round to something tiny or negative
+	task.attempt = 62
+	before = time.Now()
+	scheduler.retry(task)
+	wait = task.runAt.Sub(before)
+	assert.True(t, wait >= 0 && wait <= schedulerBackoffCap, "backoff must be capped at schedulerBackoffCap")
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2015 Home Office All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	// defaultUpdateInterval is used when a resource spec doesn't override the refresh interval
+	defaultUpdateInterval = 15 * time.Minute
+)
+
+// the recognised keys for VaultResource.options - passed straight through from the command
+// line / config into the backend responsible for the resource's engine
+const (
+	// OptionCommonName ... the common_name used when issuing a pki certificate
+	OptionCommonName = "common_name"
+	// OptionVersion ... pins a specific version of a kv v2 secret
+	OptionVersion = "version"
+	// OptionTransitOperation ... selects the transit operation: encrypt, decrypt or (the
+	// default) datakey
+	OptionTransitOperation = "operation"
+	// OptionPlaintext ... the plaintext to encrypt via the transit engine
+	OptionPlaintext = "plaintext"
+	// OptionCiphertext ... the ciphertext to decrypt via the transit engine
+	OptionCiphertext = "ciphertext"
+	// OptionPublicKeyFile ... the file containing the public key to sign via the ssh engine
+	OptionPublicKeyFile = "public_key_file"
+	// OptionWrapTTL ... response-wraps the get request for this resource, valid for the
+	// given ttl, e.g. "5m"
+	OptionWrapTTL = "wrap_ttl"
+)
+
+// VaultResource ... describes a single secret to retrieve from vault and keep fresh on disk,
+// parsed off a single "-resource" command line / config entry
+type VaultResource struct {
+	// resource is the vault secret engine mount this resource is read from, e.g. pki, kv, secret
+	resource string
+	// name is the role, path or secret name within the engine
+	name string
+	// path is the file the rendered secret is written to
+	path string
+	// format controls how writeResource renders the secret to disk
+	format string
+	// update is how often the resource is refreshed, also passed to vault as the renewal increment
+	update time.Duration
+	// revoked, when true, revokes the previous lease once a replacement has been retrieved
+	revoked bool
+	// renewBehavior controls what happens once a secret's LifetimeWatcher ends
+	renewBehavior RenewBehavior
+	// options carries engine and resource specific tuning, e.g. common_name, version, wrap_ttl
+	options map[string]string
+}
+
+// String ... gives a human readable reference to the resource, used throughout the logging
+func (r VaultResource) String() string {
+	return fmt.Sprintf("%s/%s", r.resource, r.name)
+}
+
+// IsValid ... sanity checks a resource parsed off the command line before we start watching it
+func (r VaultResource) IsValid() error {
+	if r.resource == "" {
+		return fmt.Errorf("resource has not specified a vault secret engine")
+	}
+	if r.name == "" {
+		return fmt.Errorf("resource: %s has not specified a name", r.resource)
+	}
+	if r.path == "" {
+		return fmt.Errorf("resource: %s has not specified an output path", r)
+	}
+	if _, found := backends[r.resource]; !found {
+		return fmt.Errorf("resource: %s is using an unsupported vault secret engine", r)
+	}
+
+	return r.isValidForEngine()
+}
+
+// isValidForEngine ... checks the engine-specific options each backend relies on are present,
+// so a missing option is caught here rather than surfacing as a vault error at fetch time
+func (r VaultResource) isValidForEngine() error {
+	switch r.resource {
+	case "pki":
+		if r.options[OptionCommonName] == "" {
+			return fmt.Errorf("resource: %s is missing the required %q option", r, OptionCommonName)
+		}
+	case "ssh":
+		if r.options[OptionPublicKeyFile] == "" {
+			return fmt.Errorf("resource: %s is missing the required %q option", r, OptionPublicKeyFile)
+		}
+	case "transit":
+		switch r.options[OptionTransitOperation] {
+		case "encrypt":
+			if r.options[OptionPlaintext] == "" {
+				return fmt.Errorf("resource: %s is missing the required %q option", r, OptionPlaintext)
+			}
+		case "decrypt":
+			if r.options[OptionCiphertext] == "" {
+				return fmt.Errorf("resource: %s is missing the required %q option", r, OptionCiphertext)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseResource ... parses a single "-resource" specification in the form
+//	<engine>/<name>:<output path>[,<key>=<value>...]
+// into a VaultResource, e.g. "pki/example.com:/etc/ssl/example.com,common_name=example.com"
+func parseResource(spec string) (*VaultResource, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid resource specification: %s, expected <engine>/<name>:<path>[,options]", spec)
+	}
+
+	engine := strings.SplitN(parts[0], "/", 2)
+	if len(engine) != 2 {
+		return nil, fmt.Errorf("invalid resource specification: %s, expected <engine>/<name>", parts[0])
+	}
+
+	fields := strings.Split(parts[1], ",")
+	rn := &VaultResource{
+		resource:      engine[0],
+		name:          engine[1],
+		path:          fields[0],
+		format:        "yaml",
+		update:        defaultUpdateInterval,
+		revoked:       true,
+		renewBehavior: RenewUntilMaxTTLThenReissue,
+		options:       make(map[string]string),
+	}
+
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid resource option: %s, expected key=value", field)
+		}
+		key, value := kv[0], kv[1]
+
+		switch key {
+		case "fmt":
+			rn.format = value
+		case "update":
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid update interval: %s, error: %s", value, err)
+			}
+			rn.update = interval
+		case "revoked":
+			rn.revoked = value != "false"
+		case "renew":
+			if value == "reissue" {
+				rn.renewBehavior = AlwaysReissueOnLeaseEnd
+			}
+		default:
+			// step: anything else is passed straight through as a backend/auth option, e.g.
+			// common_name, version, operation, plaintext, ciphertext, public_key_file, wrap_ttl
+			rn.options[key] = value
+		}
+	}
+
+	return rn, nil
+}
+
+// watchedResource ... pairs a VaultResource with the last secret retrieved for it; this is the
+// unit of work handed around the service processor for the lifetime of the watch
+type watchedResource struct {
+	// resource is the static configuration for this watch
+	resource *VaultResource
+	// secret is the last secret (and lease) retrieved from vault for this resource
+	secret *api.Secret
+	// lastUpdated records when secret was last refreshed
+	lastUpdated time.Time
+}
+
+// String ... delegates to the underlying resource for logging
+func (r watchedResource) String() string {
+	return r.resource.String()
+}
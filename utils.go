@@ -0,0 +1,69 @@
+/*
+Copyright 2015 Home Office All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"time"
+
+	"github.com/golang/glog"
+	"gopkg.in/yaml.v2"
+)
+
+// randomWait ... returns a channel which fires once after a random number of seconds between
+// min and max, used to jitter retry attempts so a pile of failing resources don't all retry
+// in lockstep
+//	min			: the minimum amount of time i'm willing to wait
+//	max			: the maximum amount of time i'm willing to wait
+func randomWait(min, max int) <-chan time.Time {
+	wait := time.Duration(min+rand.Intn(max-min+1)) * time.Second
+
+	return time.After(wait)
+}
+
+// writeResource ... renders a resource's secret data and writes it to its configured path
+//	resource	: the resource the data belongs to
+//	data		: the secret data retrieved from vault
+func writeResource(resource *VaultResource, data map[string]interface{}) {
+	content, err := formatResource(resource.format, data)
+	if err != nil {
+		glog.Errorf("failed to format the resource: %s, error: %s", resource, err)
+		return
+	}
+
+	if err := ioutil.WriteFile(resource.path, content, 0660); err != nil {
+		glog.Errorf("failed to write the resource: %s to: %s, error: %s", resource, resource.path, err)
+		return
+	}
+
+	glog.V(3).Infof("wrote resource: %s to: %s", resource, resource.path)
+}
+
+// formatResource ... renders secret data according to the resource's requested output format
+func formatResource(format string, data map[string]interface{}) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.MarshalIndent(data, "", "  ")
+	case "yaml", "":
+		return yaml.Marshal(data)
+	default:
+		return nil, fmt.Errorf("unsupported output format: %s", format)
+	}
+}
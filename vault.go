@@ -19,9 +19,12 @@ package main
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
@@ -31,6 +34,27 @@ import (
 const (
 	// VaultAuth ... the method to use when authenticating to vault
 	VaultAuth = "method"
+	// maxReauthAttempts ... how many consecutive times we'll retry re-authenticating the
+	// client token before giving up and failing the process outright
+	maxReauthAttempts = 5
+	// minTokenRecheckInterval ... the floor between LookupSelf calls when the login token's
+	// LifetimeWatcher exits without ever renewing, e.g. a non-renewable, zero-ttl token such
+	// as a dev-mode root token
+	minTokenRecheckInterval = 5 * time.Minute
+)
+
+// RenewBehavior ... describes what a watchedResource should do once the
+// LifetimeWatcher for its current secret ends
+type RenewBehavior int
+
+const (
+	// RenewUntilMaxTTLThenReissue ... keep renewing the lease, ignoring
+	// one-off renewal errors, until vault refuses (the max TTL has been
+	// reached), at which point a brand new secret is requested
+	RenewUntilMaxTTLThenReissue RenewBehavior = iota
+	// AlwaysReissueOnLeaseEnd ... never attempt to renew, simply wait for
+	// the lease to run its course and request a brand new secret
+	AlwaysReissueOnLeaseEnd
 )
 
 // AuthInterface .. the auth interface
@@ -46,6 +70,9 @@ type VaultService struct {
 	client *api.Client
 	// the vault config
 	config *api.Config
+	// tokenLock guards token and the calls to client.SetToken so in-flight get/renew
+	// calls never see a half-swapped token while we're re-authenticating
+	tokenLock sync.Mutex
 	// the token to authenticate with
 	token string
 
@@ -53,13 +80,33 @@ type VaultService struct {
 	listeners []chan VaultEvent
 	// a channel to inform of a new resource to processor
 	resourceChannel chan *watchedResource
+	// closeOnce guards the shutdown channel so Close() is safe to call once
+	closeOnce sync.Once
+	// shutdownChannel is closed when Close() is called, stopping every lifetime watcher
+	shutdownChannel chan struct{}
+	// the number of resources currently being watched, used purely for stats
+	watching int64
+	// scheduler drives retries of failed get/renew/revoke calls with capped exponential backoff
+	scheduler *renewalScheduler
 }
 
+// EventKind ... distinguishes the different kinds of event an operator might want to observe
+type EventKind int
+
+const (
+	// ResourceUpdated ... a watched resource has been retrieved or renewed, Resource/Secret are set
+	ResourceUpdated EventKind = iota
+	// ReAuthenticated ... the client token expired and was replaced by a fresh login
+	ReAuthenticated
+)
+
 // VaultEvent ... the definition which captures a change
 type VaultEvent struct {
-	// the resource this relates to
+	// Kind distinguishes a resource update from a re-authentication notice
+	Kind EventKind
+	// the resource this relates to, nil for a ReAuthenticated event
 	Resource *VaultResource
-	// the secret associated
+	// the secret associated, nil for a ReAuthenticated event
 	Secret map[string]interface{}
 }
 
@@ -73,6 +120,8 @@ func NewVaultService(url string) (*VaultService, error) {
 	service.config = api.DefaultConfig()
 	service.config.Address = url
 	service.listeners = make([]chan VaultEvent, 0)
+	service.shutdownChannel = make(chan struct{})
+	service.scheduler = newRenewalScheduler()
 
 	// step: setup and generate the tls options
 	service.config.HttpClient.Transport, err = service.getHttpTransport()
@@ -95,7 +144,15 @@ func NewVaultService(url string) (*VaultService, error) {
 	// step: set the token for the client
 	service.client.SetToken(service.token)
 
-	// step: start the service processor off
+	// step: keep the client token itself alive for the life of the service - a userpass /
+	// approle / kubernetes login token would otherwise silently expire and every subsequent
+	// get / renew / revoke would start 403'ing
+	if err := service.startTokenRenewal(options.vaultAuthOptions); err != nil {
+		return nil, err
+	}
+
+	// step: start the renewal scheduler and the service processor off
+	service.scheduler.Start(service.shutdownChannel)
 	service.vaultServiceProcessor()
 
 	return service, nil
@@ -132,137 +189,350 @@ func (r *VaultService) AddListener(ch chan VaultEvent) {
 
 // Watch ... add a watch on a resource and inform, renew which required and inform us when
 // the resource is ready
-func (r VaultService) Watch(rn *VaultResource) {
+func (r *VaultService) Watch(rn *VaultResource) {
 	r.resourceChannel <- &watchedResource{resource: rn}
 }
 
-// vaultServiceProcessor ... is the background routine responsible for retrieving the resources, renewing when required and
-// informing those who are watching the resource that something has changed
+// vaultServiceProcessor ... is the background routine responsible for picking up newly watched
+// resources and handing each one off to its own lifetime, renewing when required and informing
+// those who are watching the resource that something has changed
 func (r *VaultService) vaultServiceProcessor() {
 	go func() {
-		// a list of resource being watched
-		var items []*watchedResource
-
-		// the channel to receive renewal notifications on
-		renewChannel := make(chan *watchedResource, 10)
-		retrieveChannel := make(chan *watchedResource, 10)
-		revokeChannel := make(chan string, 10)
 		statsChannel := time.NewTicker(options.statsInterval)
+		defer statsChannel.Stop()
 
 		for {
 			select {
-			// A new resource is being added to the service processor;
-			//  - we retrieve the resource from vault
-			//  - if we error attempting to retrieve the secret, we background and reschedule an attempt to add it
-			//  - if ok, we grab the lease it and lease time, we setup a notification on renewal
+			// A new resource is being added to the service processor - it gets its own
+			// goroutine for the remainder of its lifetime, driven off a LifetimeWatcher
 			case x := <-r.resourceChannel:
 				glog.V(4).Infof("adding a resource into the service processor, resource: %s", x.resource)
-				// step: add to the list of resources
-				items = append(items, x)
-				// step: push into the retrieval channel
-				retrieveChannel <- x
-
-			case x := <-retrieveChannel:
-				// step: save the current lease if we have one
-				leaseID := ""
-				if x.secret != nil && x.secret.LeaseID != "" {
-					leaseID = x.secret.LeaseID
-					glog.V(10).Infof("resource: %s has a previous lease: %s", x.resource, leaseID)
-				}
+				atomic.AddInt64(&r.watching, 1)
+				go r.watch(x)
 
-				// step: retrieve the resource from vault
-				err := r.get(x)
-				if err != nil {
-					glog.Errorf("failed to retrieve the resource: %s from vault, error: %s", x.resource, err)
-					// reschedule the attempt for later
-					r.reschedule(x, retrieveChannel, 3, 10)
-					break
-				}
+			// The statistics timer has gone off
+			case <-statsChannel.C:
+				glog.V(3).Infof("stats: %d resources being watched, %d renewal/revoke attempts pending",
+					atomic.LoadInt64(&r.watching), r.scheduler.Len())
+
+			case <-r.shutdownChannel:
+				return
+			}
+		}
+	}()
+}
+
+// watch ... owns the entire lifetime of a single watched resource, for as long as the service
+// runs: fetch the secret, then keep it fresh with a LifetimeWatcher until the watcher cleanly
+// runs out (the lease/token has hit its max TTL), at which point it is re-fetched and the cycle
+// repeats. A failed fetch is handed to the renewalScheduler with capped exponential backoff
+// rather than blocking this goroutine in an ad-hoc sleep.
+func (r *VaultService) watch(x *watchedResource) {
+	if !r.fetch(x) {
+		// step: ownership of the resource has passed to the renewalScheduler's retry task,
+		// which will carry the "watching" count through to its own exit
+		return
+	}
+
+	for r.runLifetimeWatcher(x) {
+		if !r.fetch(x) {
+			return
+		}
+	}
+
+	// step: runLifetimeWatcher only returns false once, on shutdown - this resource is done
+	atomic.AddInt64(&r.watching, -1)
+}
 
-				glog.V(4).Infof("successfully retrieved resournce: %s, leaseID: %s", x.resource, x.secret.LeaseID)
+// fetch ... retrieves the resource from vault, revokes the previous lease if configured to do
+// so, and pushes the result upstream. On failure the attempt is enqueued on the renewalScheduler
+// with capped exponential backoff, resuming the watch lifecycle once it eventually succeeds;
+// the return value tells the caller whether it can carry straight on (true) or must stop (false).
+func (r *VaultService) fetch(x *watchedResource) bool {
+	// step: save the current lease, so we can revoke it once we have a replacement
+	leaseID := ""
+	if x.secret != nil && x.secret.LeaseID != "" {
+		leaseID = x.secret.LeaseID
+		glog.V(10).Infof("resource: %s has a previous lease: %s", x.resource, leaseID)
+	}
 
-				// step: if we had a previous lease and the option is to revoke, lets throw into the revoke channel
-				if leaseID != "" && x.resource.revoked {
-					revokeChannel <- leaseID
+	if err := r.get(x); err != nil {
+		glog.Errorf("failed to retrieve the resource: %s from vault, error: %s", x.resource, err)
+		r.scheduler.schedule(fmt.Sprintf("fetch:%s", x.resource), func() error {
+			if err := r.get(x); err != nil {
+				return err
+			}
+			r.upstream(x)
+			for r.runLifetimeWatcher(x) {
+				if !r.fetch(x) {
+					return nil
 				}
+			}
+			// step: runLifetimeWatcher only returns false once, on shutdown - this resource is done
+			atomic.AddInt64(&r.watching, -1)
+			return nil
+		})
+		return false
+	}
+
+	glog.V(4).Infof("successfully retrieved resournce: %s, leaseID: %s", x.resource, x.secret.LeaseID)
 
-				// step: setup a timer for renewal
-				x.notifyOnRenewal(renewChannel)
+	// step: if we had a previous lease and the option is to revoke, lets get rid of it
+	if leaseID != "" && x.resource.revoked {
+		r.scheduler.schedule(fmt.Sprintf("revoke:%s", x.resource), func() error {
+			return r.revoke(leaseID)
+		})
+	}
 
-				// step: update the upstream consumers
-				r.upstream(x)
+	r.upstream(x)
 
-			// A watched resource is coming up for renewal
-			// 	- we attempt to renew the resource from vault
-			//	- if we encounter an error, we reschedule the attempt for the future
-			//	- if we're ok, we update the watchedResource and we send a notification of the change upstream
-			case x := <-renewChannel:
+	return true
+}
 
-				glog.V(4).Infof("resource: %s, lease: %s up for renewal, renewable: %t, revoked: %t", x.resource,
-					x.secret.LeaseID, x.resource.renewable, x.resource.revoked)
+// runLifetimeWatcher ... starts a LifetimeWatcher on the resource's current secret and drives it
+// until it exits - either because the lease/token ran its course (a re-fetch is required) or
+// because the service was closed, in which case false is returned and the caller should stop
+func (r *VaultService) runLifetimeWatcher(x *watchedResource) bool {
+	behavior := api.RenewBehaviorIgnoreErrors
+	if x.resource.renewBehavior == AlwaysReissueOnLeaseEnd {
+		behavior = api.RenewBehaviorRenewDisabled
+	}
 
-				// step: we need to check if the lease has expired?
-				if time.Now().Before(x.leaseExpireTime) {
-					glog.V(3).Infof("the lease on resource: %s has expired, we need to get a new lease", x.resource)
-					// push into the retrieval channel and break
-					retrieveChannel <- x
-					break
-				}
+	lw, err := r.client.NewLifetimeWatcher(&api.LifetimeWatcherInput{
+		Secret:        x.secret,
+		Increment:     int(x.resource.update.Seconds()),
+		RenewBehavior: behavior,
+	})
+	if err != nil {
+		glog.Errorf("unable to create a lifetime watcher for resource: %s, error: %s", x.resource, err)
+		return true
+	}
 
-				// step: are we renewing the resource?
-				if x.resource.renewable {
-					// step: is the underlining resource even renewable? - otherwise we can just grab a new lease
-					if !x.secret.Renewable {
-						glog.V(10).Infof("the resource: %s is not renewable, retrieving a new lease instead", x.resource)
-						retrieveChannel <- x
-						break
-					}
-
-					// step: lets renew the resource
-					err := r.renew(x)
-					if err != nil {
-						glog.Errorf("failed to renew the resounce: %s for renewal, error: %s", x.resource, err)
-						// reschedule the attempt for later
-						r.reschedule(x, renewChannel, 3, 10)
-						break
-					}
-				}
+	started := time.Now()
+	renewed := false
+	go lw.Start()
+
+	for {
+		select {
+		// the watcher successfully renewed the lease/token - push the refreshed secret upstream
+		case renewal := <-lw.RenewCh():
+			glog.V(4).Infof("renewed the lease on resource: %s, leaseId: %s", x.resource, x.secret.LeaseID)
+			renewed = true
+			x.secret = renewal.Secret
+			x.lastUpdated = renewal.RenewedAt
+			r.upstream(x)
+
+		// the watcher has given up - either a renewal error, or the lease/token has simply run
+		// out (non-renewable secrets end up here too); either way we need a fresh secret
+		case err := <-lw.DoneCh():
+			if err != nil {
+				glog.Errorf("the lifetime watcher for resource: %s exited with error: %s, re-fetching", x.resource, err)
+			} else {
+				glog.V(4).Infof("the lease on resource: %s has run its course, regenerating the resource", x.resource)
+			}
+			if !renewed {
+				// the watcher never renewed, meaning it had nothing to pace off - either the
+				// secret carries no real lease at all (ssh, transit, a response-wrapped
+				// resource) or a backend forgot to patch one in, and DoneCh fires in
+				// microseconds in that case; enforce the resource's own refresh interval
+				// ourselves rather than hammering vault as fast as we can re-fetch
+				return r.waitForNextUpdate(x, started)
+			}
+			return true
 
-				// step: the option for this resource is not to renew the secret but regenerate a new secret
-				if !x.resource.renewable {
-					glog.V(4).Infof("resource: %s flagged as not renewable, shifting to regenerating the resource", x.resource)
-					retrieveChannel <- x
-					break
-				}
+		case <-r.shutdownChannel:
+			lw.Stop()
+			return false
+		}
+	}
+}
+
+// waitForNextUpdate ... blocks until resource.update has elapsed since the watcher in question
+// was started, used as a floor when a LifetimeWatcher exits without ever renewing
+func (r *VaultService) waitForNextUpdate(x *watchedResource, since time.Time) bool {
+	remaining := x.resource.update - time.Since(since)
+	if remaining <= 0 {
+		return true
+	}
+
+	select {
+	case <-time.After(remaining):
+		return true
+	case <-r.shutdownChannel:
+		return false
+	}
+}
 
-				// step: setup a timer for renewal
-				x.notifyOnRenewal(renewChannel)
+// Close ... stops the service processor and every active lifetime watcher, used on shutdown so
+// tests (and the real process) don't leak goroutines
+func (r *VaultService) Close() {
+	r.closeOnce.Do(func() {
+		close(r.shutdownChannel)
+	})
+}
 
-				// step: update any listener upstream
-				r.upstream(x)
+// startTokenRenewal ... looks up the renewal properties of the login token and, assuming we
+// can learn them, starts a background goroutine which rides an api.LifetimeWatcher on the
+// token until it hits its max TTL and then re-invokes the original auth plugin for a new one
+//	auth		: the auth options the login token was originally obtained with
+func (r *VaultService) startTokenRenewal(auth map[string]string) error {
+	secret, err := r.tokenSecret()
+	if err != nil {
+		return fmt.Errorf("unable to determine the renewal properties of the login token, error: %s", err)
+	}
+	if !secret.Auth.Renewable {
+		glog.Warningf("the login token is not renewable, it will be replaced by re-authenticating once it expires")
+	}
 
-			case lease := <-revokeChannel:
+	go func() {
+		current := secret
+		for {
+			// step: ride the token until the watcher gives up - either a renewal error or
+			// the token has hit its max ttl - or we're told to shut down
+			if !r.watchLoginToken(current) {
+				return
+			}
 
-				err := r.revoke(lease)
-				if err != nil {
-					glog.Errorf("failed to revoke the lease: %s, error: %s", lease, err)
+			// step: the token is done for, re-authenticate for a fresh one, retrying on
+			// failure up to maxReauthAttempts - without a valid token every subsequent
+			// get/renew/revoke would just 403, so beyond that we fail fast and stop the process
+			fresh, err := r.reauthenticate(auth)
+			for attempt := 1; err != nil; attempt++ {
+				glog.Errorf("failed to re-authenticate and refresh the client token, error: %s", err)
+				if attempt >= maxReauthAttempts {
+					glog.Fatalf("unable to re-authenticate against vault after %d attempts, giving up: %s", attempt, err)
 				}
-
-			// The statistics timer has gone off; we iterate the watched items and
-			case <-statsChannel.C:
-				glog.V(3).Infof("stats: %d resources being watched", len(items))
-				for _, item := range items {
-					glog.V(3).Infof("resourse: %s, lease id: %s, renewal in: %s seconds, expiration: %s",
-						item.resource, item.secret.LeaseID, item.renewalTime, item.leaseExpireTime)
+				select {
+				case <-randomWait(3, 10):
+				case <-r.shutdownChannel:
+					return
 				}
+				fresh, err = r.reauthenticate(auth)
 			}
+			current = fresh
 		}
 	}()
+
+	return nil
+}
+
+// watchLoginToken ... drives an api.LifetimeWatcher over the client's login token, returning
+// true once the caller should re-authenticate (the watcher exited, renewably or not) and false
+// if the service was closed while watching
+func (r *VaultService) watchLoginToken(secret *api.Secret) bool {
+	lw, err := r.client.NewLifetimeWatcher(&api.LifetimeWatcherInput{Secret: secret})
+	if err != nil {
+		glog.Errorf("unable to create a lifetime watcher for the client token, error: %s", err)
+		return true
+	}
+
+	started := time.Now()
+	renewed := false
+	go lw.Start()
+
+	for {
+		select {
+		case <-lw.RenewCh():
+			glog.V(4).Infof("renewed the client auth token")
+			renewed = true
+
+		case err := <-lw.DoneCh():
+			if err != nil {
+				glog.Errorf("failed to renew the client auth token, error: %s, re-authenticating", err)
+			} else {
+				glog.V(4).Infof("the client auth token has run its course, re-authenticating")
+			}
+			if !renewed {
+				// a non-renewable, zero-ttl token (a dev-mode root token, or any token
+				// minted without a ttl - the single most common shape of "-auth
+				// method=token") makes the watcher return immediately every time;
+				// without a floor here, startTokenRenewal would call reauthenticate,
+				// and so tokenSecret's LookupSelf, back to back forever
+				return r.waitForTokenRecheck(started)
+			}
+			return true
+
+		case <-r.shutdownChannel:
+			lw.Stop()
+			return false
+		}
+	}
+}
+
+// waitForTokenRecheck ... blocks until minTokenRecheckInterval has elapsed since the watcher
+// in question was started, used as a floor when the login token's LifetimeWatcher exits
+// without ever renewing
+func (r *VaultService) waitForTokenRecheck(since time.Time) bool {
+	remaining := minTokenRecheckInterval - time.Since(since)
+	if remaining <= 0 {
+		return true
+	}
+
+	select {
+	case <-time.After(remaining):
+		return true
+	case <-r.shutdownChannel:
+		return false
+	}
+}
+
+// reauthenticate ... re-invokes the original auth plugin, swaps the fresh token into the
+// client under tokenLock and emits a ReAuthenticated event so operators can observe it
+//	auth		: the auth options to re-authenticate with
+func (r *VaultService) reauthenticate(auth map[string]string) (*api.Secret, error) {
+	token, err := r.authenticate(auth)
+	if err != nil {
+		return nil, err
+	}
+
+	r.tokenLock.Lock()
+	r.token = token
+	r.client.SetToken(token)
+	r.tokenLock.Unlock()
+
+	glog.Infof("re-authenticated against vault and replaced the client token")
+	r.upstreamReAuth()
+
+	return r.tokenSecret()
+}
+
+// tokenSecret ... looks up the renewal metadata for the client's current token and wraps it
+// in the shape api.LifetimeWatcher expects
+func (r *VaultService) tokenSecret() (*api.Secret, error) {
+	self, err := r.client.Auth().Token().LookupSelf()
+	if err != nil {
+		return nil, err
+	}
+
+	renewable, _ := self.Data["renewable"].(bool)
+	var leaseDuration int
+	if ttl, ok := self.Data["ttl"].(json.Number); ok {
+		if seconds, err := ttl.Int64(); err == nil {
+			leaseDuration = int(seconds)
+		}
+	}
+
+	return &api.Secret{
+		Auth: &api.SecretAuth{
+			ClientToken:   r.token,
+			Renewable:     renewable,
+			LeaseDuration: leaseDuration,
+		},
+	}, nil
+}
+
+// upstreamReAuth ... informs every listener that the client token has been replaced
+func (r *VaultService) upstreamReAuth() {
+	for _, listener := range r.listeners {
+		go func(ch chan VaultEvent) {
+			ch <- VaultEvent{Kind: ReAuthenticated}
+		}(listener)
+	}
 }
 
 // authenticate ... we need to authenticate to teh vault to grab a toke
 //	auth		: a map containing the options required for authentication
-func (r VaultService) authenticate(auth map[string]string) (string, error) {
+func (r *VaultService) authenticate(auth map[string]string) (string, error) {
 	var secret string
 	var err error
 
@@ -273,6 +543,12 @@ func (r VaultService) authenticate(auth map[string]string) (string, error) {
 	case "token":
 		auth["filename"] = options.vaultAuthFile
 		secret, err = NewUserTokenPlugin(r.client).Create(auth)
+	case "approle":
+		secret, err = NewAppRolePlugin(r.client).Create(auth)
+	case "kubernetes":
+		secret, err = NewKubernetesPlugin(r.client).Create(auth)
+	case "wrapped":
+		secret, err = NewWrappedPlugin(r.client).Create(auth)
 	default:
 		return "", fmt.Errorf("unsupported authentication plugin: %s", plugin)
 	}
@@ -280,61 +556,25 @@ func (r VaultService) authenticate(auth map[string]string) (string, error) {
 	return secret, err
 }
 
-// reschedule ... reschedules an event back into a channel after n seconds
-//	rn			: a pointer to the watched resource you wish to reschedule
-//	ch			: the channel the resource should be placed into
-//	min			: the minimum amount of time i'm willing to wait
-//	max			: the maximum amount of time i'm willing to wait
-func (r VaultService) reschedule(rn *watchedResource, ch chan *watchedResource, min, max int) {
-	go func(x *watchedResource) {
-		glog.V(3).Infof("rescheduling the resource: %s, channel: %v", rn.resource, ch)
-		<-randomWait(min, max)
-		ch <- x
-	}(rn)
-}
-
 // upstream ... the resource has changed thus we notify the upstream listener
 //	item		: the item which has changed
-func (r VaultService) upstream(item *watchedResource) {
+func (r *VaultService) upstream(item *watchedResource) {
 	// step: chunk this into a go-routine not to block us
 	for _, listener := range r.listeners {
-		go func() {
-			glog.V(6).Infof("sending the event for resource: %s upstream to listener: %v", item.resource, listener)
-			listener <- VaultEvent{
+		go func(ch chan VaultEvent) {
+			glog.V(6).Infof("sending the event for resource: %s upstream to listener: %v", item.resource, ch)
+			ch <- VaultEvent{
+				Kind:     ResourceUpdated,
 				Resource: item.resource,
 				Secret:   item.secret.Data,
 			}
-		}()
-	}
-}
-
-// renew ... attempts to renew the lease on a resource
-// 	rn			: the resource we wish to renew the lease on
-func (r VaultService) renew(rn *watchedResource) error {
-	glog.V(4).Infof("attempting to renew the lease: %s on resource: %s", rn.secret.LeaseID, rn.resource)
-	// step: check the resource is renewable
-	if !rn.secret.Renewable {
-		return fmt.Errorf("the resource: %s is not renewable", rn.resource)
-	}
-
-	secret, err := r.client.Sys().Renew(rn.secret.LeaseID, 0)
-	if err != nil {
-		return err
+		}(listener)
 	}
-
-	// step: update the resource
-	rn.lastUpdated = time.Now()
-	rn.leaseExpireTime = rn.lastUpdated.Add(time.Duration(secret.LeaseDuration))
-
-	glog.V(3).Infof("renewed resource: %s, leaseId: %s, lease_time: %s, expiration: %s",
-		rn.resource, rn.secret.LeaseID, rn.secret.LeaseID, rn.leaseExpireTime)
-
-	return nil
 }
 
 // revoke ... attempt to revoke the lease of a resource
 //	lease		: the lease lease which was given when you got it
-func (r VaultService) revoke(lease string) error {
+func (r *VaultService) revoke(lease string) error {
 	glog.V(3).Infof("attemping to revoking the lease: %s", lease)
 
 	err := r.client.Sys().Revoke(lease)
@@ -346,44 +586,74 @@ func (r VaultService) revoke(lease string) error {
 	return nil
 }
 
-// get ... retrieve a secret from the vault
+// get ... retrieve a secret from the vault, dispatching to the backend registered for the
+// resource's engine rather than switching on the engine name directly
 //	rn			: the watched resource
-func (r VaultService) get(rn *watchedResource) (err error) {
-	var secret *api.Secret
+func (r *VaultService) get(rn *watchedResource) error {
 	glog.V(5).Infof("attempting to retrieve the resource: %s from vault", rn.resource)
 
-	switch rn.resource.resource {
-	case "pki":
-		secret, err = r.client.Logical().Write(fmt.Sprintf("%s/issue/%s", rn.resource.resource, rn.resource.name),
-			map[string]interface{}{
-				"common_name": rn.resource.options[OptionCommonName],
-			})
-	case "aws":
-		secret, err = r.client.Logical().Read(fmt.Sprintf("%s/creds/%s", rn.resource.resource, rn.resource.name))
-	case "mysql":
-		secret, err = r.client.Logical().Read(fmt.Sprintf("%s/creds/%s", rn.resource.resource, rn.resource.name))
-	case "secret":
-		secret, err = r.client.Logical().Read(fmt.Sprintf("%s/%s", rn.resource.resource, rn.resource.name))
-	}
-	// step: return on error
+	b, found := backends[rn.resource.resource]
+	if !found {
+		return fmt.Errorf("unsupported resource type: %s", rn.resource.resource)
+	}
+
+	client, err := r.wrappingClient(rn.resource)
 	if err != nil {
 		return err
 	}
-	if secret == nil && err != nil {
-		return fmt.Errorf("the resource does not exist")
-	}
 
+	secret, err := b.Fetch(client, rn.resource)
+	if err != nil {
+		return err
+	}
 	if secret == nil {
 		return fmt.Errorf("unable to retrieve the secret")
 	}
 
+	// step: a response-wrapped secret carries no Data of its own - what gets renewed /
+	// written out is the WrapInfo itself, so downstream consumers can unwrap it themselves
+	if secret.WrapInfo != nil {
+		glog.V(3).Infof("resource: %s was response-wrapped, accessor: %s, creation path: %s",
+			rn.resource, secret.WrapInfo.Accessor, secret.WrapInfo.CreationPath)
+		secret.Data = map[string]interface{}{
+			"token":         secret.WrapInfo.Token,
+			"accessor":      secret.WrapInfo.Accessor,
+			"creation_path": secret.WrapInfo.CreationPath,
+			"creation_time": secret.WrapInfo.CreationTime,
+			"ttl":           secret.WrapInfo.TTL,
+		}
+		secret.Renewable = false
+		secret.LeaseDuration = int(rn.resource.update.Seconds())
+	}
+
 	// step: update the watched resource
 	rn.lastUpdated = time.Now()
 	rn.secret = secret
-	rn.leaseExpireTime = rn.lastUpdated.Add(time.Duration(secret.LeaseDuration))
 
 	glog.V(3).Infof("retrieved resource: %s, leaseId: %s, lease_time: %s",
 		rn.resource, rn.secret.LeaseID, time.Duration(rn.secret.LeaseDuration)*time.Second)
 
-	return err
+	return nil
+}
+
+// wrappingClient ... returns the shared vault client, unless the resource has requested
+// response-wrapping via options[OptionWrapTTL], in which case a clone is returned with a
+// wrapping lookup function set so only this one call gets wrapped
+//	resource	: the resource that may be asking for a wrapped response
+func (r *VaultService) wrappingClient(resource *VaultResource) (*api.Client, error) {
+	wrapTTL, found := resource.options[OptionWrapTTL]
+	if !found || wrapTTL == "" {
+		return r.client, nil
+	}
+
+	client, err := r.client.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("unable to clone the vault client for a wrapped request, error: %s", err)
+	}
+	client.SetToken(r.client.Token())
+	client.SetWrappingLookupFunc(func(operation, path string) string {
+		return wrapTTL
+	})
+
+	return client, nil
 }
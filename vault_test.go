@@ -0,0 +1,53 @@
+/*
+Copyright 2015 Home Office All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVaultServiceCloseStopsLifetimeWatchers(t *testing.T) {
+	service := &VaultService{shutdownChannel: make(chan struct{})}
+
+	leaked := make(chan struct{})
+	go func() {
+		// step: stands in for the goroutines runLifetimeWatcher/watchLoginToken block in,
+		// all of which select on shutdownChannel to know when to stop
+		<-service.shutdownChannel
+		close(leaked)
+	}()
+
+	service.Close()
+
+	select {
+	case <-leaked:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not close the shutdown channel, the goroutine would leak")
+	}
+}
+
+func TestVaultServiceCloseIsIdempotent(t *testing.T) {
+	service := &VaultService{shutdownChannel: make(chan struct{})}
+
+	assert.NotPanics(t, func() {
+		service.Close()
+		service.Close()
+	})
+}